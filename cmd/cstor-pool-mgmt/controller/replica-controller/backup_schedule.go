@@ -0,0 +1,145 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/robfig/cron"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/openebs/maya/cmd/cstor-pool-mgmt/controller/common"
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+	"github.com/openebs/maya/pkg/volumereplica"
+)
+
+// backupScheduler runs one cron job per CStorVolumeReplica UID that carries
+// a spec.BackupSchedule, taking periodic zfs snapshots and garbage
+// collecting old ones beyond the configured retention count.
+type backupScheduler struct {
+	controller *CStorVolumeReplicaController
+
+	mux    sync.Mutex
+	timers map[types.UID]*cron.Cron
+}
+
+func newBackupScheduler(controller *CStorVolumeReplicaController) *backupScheduler {
+	return &backupScheduler{
+		controller: controller,
+		timers:     make(map[types.UID]*cron.Cron),
+	}
+}
+
+// Sync arms the cron job for cVR when spec.BackupSchedule is set, replacing
+// any job already armed for its UID on every call, and tears the job down
+// when spec.BackupSchedule is cleared. It is safe to call repeatedly from
+// AddFunc/UpdateFunc.
+func (b *backupScheduler) Sync(cVR *apis.CStorVolumeReplica) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	existing, ok := b.timers[cVR.UID]
+
+	if cVR.Spec.BackupSchedule == nil || cVR.Spec.BackupSchedule.Schedule == "" {
+		if ok {
+			existing.Stop()
+			delete(b.timers, cVR.UID)
+		}
+		return
+	}
+
+	// Re-arm: stop the old cron and start a fresh one rather than trying to
+	// mutate a running robfig/cron instance.
+	if ok {
+		existing.Stop()
+		delete(b.timers, cVR.UID)
+	}
+
+	c := cron.New()
+	name, namespace, uid := cVR.Name, cVR.Namespace, cVR.UID
+	schedule := cVR.Spec.BackupSchedule.Schedule
+	retain := cVR.Spec.BackupSchedule.Retain
+
+	// Parse with the standard 5-field dialect, the same one nextScheduleTime
+	// uses for NextSnapshotTime.
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		klog.ErrorS(err, "failed to arm backup schedule", "schedule", schedule, "name", name, "uid", uid)
+		return
+	}
+	c.Schedule(sched, cron.FuncJob(func() {
+		b.takeSnapshot(namespace, name, uid, retain)
+	}))
+
+	c.Start()
+	b.timers[cVR.UID] = c
+}
+
+// Cancel stops and removes the cron job for a deleted CStorVolumeReplica.
+func (b *backupScheduler) Cancel(uid types.UID) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if c, ok := b.timers[uid]; ok {
+		c.Stop()
+		delete(b.timers, uid)
+	}
+}
+
+// takeSnapshot is invoked on every cron tick. It takes a zfs snapshot of the
+// replica's volume, garbage collects snapshots beyond the retention window
+// and updates the CVR's backup status conditions.
+func (b *backupScheduler) takeSnapshot(namespace, name string, uid types.UID, retain int) {
+	key := namespace + "/" + name
+	cVR, err := b.controller.getCVRByKey(key)
+	if err != nil {
+		klog.ErrorS(err, "backupSchedule: failed to fetch cVR", "name", name, "uid", uid)
+		return
+	}
+	if cVR.UID != uid {
+		// The object was deleted and recreated under the same name; the old
+		// cron job will be cancelled by DeleteFunc, nothing to do here.
+		return
+	}
+
+	fullVolName := string(apis.PoolPrefix) + cVR.Name
+	snapName := "scheduled-" + time.Now().UTC().Format("20060102150405")
+
+	now := metav1Now()
+
+	if err := volumereplica.CreateSnapshot(fullVolName, snapName); err != nil {
+		b.controller.recorder.Event(cVR, corev1.EventTypeWarning, common.FailureSnapshot, err.Error())
+		cVR.Status.BackupStatus.FailedSnapshotCount++
+		b.controller.updateCVRCondition(cVR, apis.CStorVolumeReplicaBackupFailed, err.Error())
+		return
+	}
+
+	b.controller.recorder.Event(cVR, corev1.EventTypeNormal, common.SuccessSnapshot, "created scheduled snapshot "+snapName)
+	cVR.Status.BackupStatus.LastSnapshotTime = now
+	if next := nextScheduleTime(cVR.Spec.BackupSchedule.Schedule, now.Time); next != nil {
+		cVR.Status.BackupStatus.NextSnapshotTime = *next
+	}
+
+	if err := volumereplica.GCSnapshots(fullVolName, retain); err != nil {
+		klog.ErrorS(err, "backupSchedule: GC failed", "name", name, "uid", uid)
+	}
+
+	b.controller.updateCVRCondition(cVR, apis.CStorVolumeReplicaBackupSynced, "")
+}
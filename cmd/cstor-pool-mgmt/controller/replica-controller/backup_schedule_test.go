@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"testing"
+
+	"github.com/robfig/cron"
+	"k8s.io/apimachinery/pkg/types"
+
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+)
+
+func newTestBackupScheduler() *backupScheduler {
+	return &backupScheduler{timers: make(map[types.UID]*cron.Cron)}
+}
+
+func backupCVR(uid types.UID, schedule string) *apis.CStorVolumeReplica {
+	cVR := &apis.CStorVolumeReplica{}
+	cVR.UID = uid
+	if schedule != "" {
+		cVR.Spec.BackupSchedule = &apis.BackupSchedule{Schedule: schedule, Retain: 3}
+	}
+	return cVR
+}
+
+func TestBackupSchedulerSyncArmsAndCancel(t *testing.T) {
+	b := newTestBackupScheduler()
+
+	b.Sync(backupCVR("uid-1", "0 * * * *"))
+	if _, ok := b.timers["uid-1"]; !ok {
+		t.Fatal("Sync() did not arm a cron job for a CVR with a BackupSchedule")
+	}
+
+	b.Cancel("uid-1")
+	if _, ok := b.timers["uid-1"]; ok {
+		t.Error("Cancel() left the cron job armed")
+	}
+}
+
+func TestBackupSchedulerSyncTearsDownOnScheduleRemoved(t *testing.T) {
+	b := newTestBackupScheduler()
+
+	b.Sync(backupCVR("uid-1", "0 * * * *"))
+	b.Sync(backupCVR("uid-1", ""))
+
+	if _, ok := b.timers["uid-1"]; ok {
+		t.Error("Sync() kept the cron job armed after BackupSchedule was cleared")
+	}
+}
+
+func TestBackupSchedulerSyncRearmsOnScheduleChange(t *testing.T) {
+	b := newTestBackupScheduler()
+
+	b.Sync(backupCVR("uid-1", "0 * * * *"))
+	first := b.timers["uid-1"]
+
+	b.Sync(backupCVR("uid-1", "0 0 * * *"))
+	second, ok := b.timers["uid-1"]
+	if !ok {
+		t.Fatal("Sync() did not keep a cron job armed after a schedule change")
+	}
+	if first == second {
+		t.Error("Sync() reused the existing cron.Cron instance instead of stopping and replacing it on schedule change")
+	}
+	if len(second.Entries()) != 1 {
+		t.Errorf("re-armed cron has %d entries, want exactly 1", len(second.Entries()))
+	}
+}
+
+func TestBackupSchedulerSyncIgnoresCVRWithNoBackupSchedule(t *testing.T) {
+	b := newTestBackupScheduler()
+
+	b.Sync(backupCVR("uid-1", ""))
+	if len(b.timers) != 0 {
+		t.Errorf("Sync() armed %d cron job(s) for a CVR with no BackupSchedule, want 0", len(b.timers))
+	}
+}
+
+func TestBackupSchedulerCancelUnknownUIDIsNoop(t *testing.T) {
+	b := newTestBackupScheduler()
+	b.Cancel("never-armed")
+}
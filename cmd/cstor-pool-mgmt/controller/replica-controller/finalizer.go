@@ -0,0 +1,90 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"github.com/openebs/maya/cmd/cstor-pool-mgmt/controller/common"
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+	"github.com/openebs/maya/pkg/volumereplica"
+)
+
+// addCVRFinalizer adds the protection finalizer to the CVR identified by key
+// so the object can't be removed from etcd before its zfs dataset is gone.
+// It runs off the rate-limited "add" workqueue entry rather than directly
+// off the informer callback, so a failed Update is retried with backoff
+// instead of silently leaving the CVR unprotected.
+func (c *CStorVolumeReplicaController) addCVRFinalizer(key string) error {
+	cVR, err := c.getCVRByKey(key)
+	if err != nil {
+		return err
+	}
+	if hasCVRFinalizer(cVR) {
+		return nil
+	}
+
+	cVRCopy := cVR.DeepCopy()
+	cVRCopy.ObjectMeta.Finalizers = append(cVRCopy.ObjectMeta.Finalizers, cvrFinalizer)
+
+	if _, err := c.clientset.OpenebsV1alpha1().CStorVolumeReplicas(cVRCopy.Namespace).Update(cVRCopy); err != nil {
+		return err
+	}
+	return nil
+}
+
+// destroyCVR runs `zfs destroy` for the replica identified by key and, on
+// success, removes the protection finalizer so the apiserver can finish
+// deleting the object. DeleteFunc only cancels the backup cron: this is the
+// only path that actually tears down the zfs dataset.
+func (c *CStorVolumeReplicaController) destroyCVR(key string) error {
+	cVR, err := c.getCVRByKey(key)
+	if apierrors.IsNotFound(err) {
+		// Already gone, most likely because a previous run of this same
+		// reconcile already removed the finalizer and the apiserver finished
+		// deleting the object. Nothing left to do.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if cVR.ObjectMeta.DeletionTimestamp == nil || !hasCVRFinalizer(cVR) {
+		return nil
+	}
+
+	fullVolName := string(apis.PoolPrefix) + cVR.Name
+	if err := volumereplica.Destroy(fullVolName); err != nil {
+		c.recorder.Event(cVR, corev1.EventTypeWarning, common.FailureDestroy, err.Error())
+		return err
+	}
+
+	cVRCopy := cVR.DeepCopy()
+	cVRCopy.ObjectMeta.Finalizers = removeCVRFinalizer(cVRCopy.ObjectMeta.Finalizers)
+	if _, err := c.clientset.OpenebsV1alpha1().CStorVolumeReplicas(cVRCopy.Namespace).Update(cVRCopy); err != nil {
+		return err
+	}
+
+	clearReplicaState(cVR.Name)
+	c.appliedSpecs.delete(cVR.UID)
+
+	klog.InfoS("cStorVolumeReplica destroyed, finalizer removed", "name", cVR.Name, "uid", cVR.UID)
+	c.recorder.Event(cVR, corev1.EventTypeNormal, common.SuccessDestroy, common.MessageDestroySynced)
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"github.com/openebs/maya/cmd/cstor-pool-mgmt/controller/common"
+)
+
+// Run starts the controller workers, one per workqueue shard, and blocks
+// until stopCh is closed. When config.LeaderElection.Enabled is set, the
+// workers only run while this instance holds the lease, allowing multiple
+// cstor-pool-mgmt pods to run hot-standby. When config.MetricsAddr is set,
+// Run also starts serving /metrics on it; that server is not stopped when
+// Run returns.
+func (c *CStorVolumeReplicaController) Run(stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	defer c.workqueues.ShutDown()
+
+	klog.Info("Starting CStorVolumeReplica controller")
+
+	RegisterMetrics(prometheus.DefaultRegisterer)
+	if c.config.MetricsAddr != "" {
+		ServeMetrics(c.config.MetricsAddr)
+	}
+
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, c.cStorReplicaSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	if c.config.LeaderElection.Enabled {
+		return c.runWithLeaderElection(stopCh)
+	}
+
+	c.startWorkers(stopCh)
+	<-stopCh
+	klog.Info("Shutting down CStorVolumeReplica workers")
+
+	return nil
+}
+
+// runWithLeaderElection blocks running the workers only while this instance
+// holds the configmap/lease lock, and stops them the moment it is lost.
+func (c *CStorVolumeReplicaController) runWithLeaderElection(stopCh <-chan struct{}) error {
+	lec := c.config.LeaderElection
+
+	lock, err := resourcelock.New(
+		resourcelock.ConfigMapsResourceLock,
+		lec.LockNamespace,
+		lec.LockName,
+		c.kubeclientset.CoreV1(),
+		nil,
+		resourcelock.ResourceLockConfig{Identity: lec.Identity},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create leader election lock: %s", err.Error())
+	}
+
+	leaderelection.RunOrDie(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: lec.LeaseDuration,
+		RenewDeadline: lec.RenewDeadline,
+		RetryPeriod:   lec.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			// ctx is scoped to this leadership term: it closes the moment
+			// this instance loses the lease, which is what must stop the
+			// workers, not the process-lifetime stopCh.
+			OnStartedLeading: func(ctx <-chan struct{}) {
+				klog.InfoS("became leader, starting CStorVolumeReplica workers", "identity", lec.Identity)
+				c.startWorkers(ctx)
+				<-ctx
+			},
+			OnStoppedLeading: func() {
+				klog.InfoS("lost leadership, shutting down CStorVolumeReplica workers", "identity", lec.Identity)
+			},
+		},
+	})
+
+	return nil
+}
+
+// startWorkers launches one goroutine per workqueue shard plus the gauge
+// samplers that keep workqueueDepth and replica_state up to date.
+func (c *CStorVolumeReplicaController) startWorkers(stopCh <-chan struct{}) {
+	klog.Info("Starting CStorVolumeReplica workers")
+	for _, shard := range c.workqueues.shards {
+		shard := shard
+		go wait.Until(func() { c.runWorker(shard) }, 0, stopCh)
+	}
+	go wait.Until(c.observeWorkqueueDepth, 10*time.Second, stopCh)
+	go wait.Until(c.observeReplicaStates, 30*time.Second, stopCh)
+	klog.Info("Started CStorVolumeReplica workers")
+}
+
+// runWorker is a long-running function that continually calls processNextWorkItem
+// to read and process an item from a single workqueue shard.
+func (c *CStorVolumeReplicaController) runWorker(queue workqueue.RateLimitingInterface) {
+	for c.processNextWorkItem(queue) {
+	}
+}
+
+// processNextWorkItem pops an item off the given shard and dispatches it to syncHandler.
+func (c *CStorVolumeReplicaController) processNextWorkItem(queue workqueue.RateLimitingInterface) bool {
+	obj, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+
+	err := func(obj interface{}) error {
+		defer queue.Done(obj)
+
+		q, ok := obj.(common.QueueLoad)
+		if !ok {
+			queue.Forget(obj)
+			runtime.HandleError(fmt.Errorf("expected common.QueueLoad in workqueue but got %#v", obj))
+			return nil
+		}
+
+		if err := c.syncHandler(q); err != nil {
+			queue.AddRateLimited(q)
+			return fmt.Errorf("error syncing %q: %s, requeuing", q.Key, err.Error())
+		}
+
+		queue.Forget(obj)
+		klog.InfoS("successfully synced", "key", q.Key, "operation", q.Operation)
+		return nil
+	}(obj)
+
+	if err != nil {
+		runtime.HandleError(err)
+	}
+
+	return true
+}
+
+// syncHandler dispatches a dequeued CStorVolumeReplica operation to the
+// corresponding reconciler, recording a reconcile latency observation and,
+// on failure, an error count partitioned by CVR name and pool.
+func (c *CStorVolumeReplicaController) syncHandler(q common.QueueLoad) error {
+	start := time.Now()
+	defer func() {
+		reconcileLatencySeconds.WithLabelValues(q.Operation).Observe(time.Since(start).Seconds())
+	}()
+
+	var err error
+	switch q.Operation {
+	case "add":
+		err = c.addCVRFinalizer(q.Key)
+		// add otherwise continues to be handled by the pre-existing
+		// pool-mgmt sync loop until it is ported here as well.
+	case "modify":
+		err = c.modifyCVR(q.Key)
+	case "destroy":
+		err = c.destroyCVR(q.Key)
+	}
+
+	if err != nil {
+		namespace, name, splitErr := cache.SplitMetaNamespaceKey(q.Key)
+		if splitErr != nil {
+			name = q.Key
+		}
+		pool := ""
+		if cVR, getErr := c.getCVRByKey(q.Key); getErr == nil {
+			pool = cVR.Spec.CStorPoolUID
+		}
+		reconcileErrorsTotal.WithLabelValues(name, pool).Inc()
+		klog.ErrorS(err, "reconcile failed", "name", name, "namespace", namespace, "operation", q.Operation)
+	}
+
+	return err
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+	"github.com/openebs/maya/pkg/volumereplica"
+)
+
+const metricsNamespace = "cstorpoolmgmt"
+const metricsSubsystem = "cvr_controller"
+
+var (
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "workqueue_depth",
+		Help:      "Current depth of the CStorVolumeReplica workqueue, per shard.",
+	}, []string{"shard"})
+
+	reconcileLatencySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reconcile_latency_seconds",
+		Help:      "Latency of reconciling a CStorVolumeReplica, per operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	reconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reconcile_errors_total",
+		Help:      "Total reconcile errors, partitioned by CVR name and pool.",
+	}, []string{"name", "pool"})
+
+	replicaState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "replica_state",
+		Help:      "Current replica state derived from zfs stats (1 for the active state, 0 otherwise), per CVR and state.",
+	}, []string{"name", "state"})
+)
+
+// RegisterMetrics registers the CVR controller's collectors against reg so
+// callers can plug in their own registry instead of the global default one.
+// It is safe to call more than once, including against a registry a caller
+// already registered these same collectors on (e.g. if they share
+// prometheus.DefaultRegisterer with Run): an AlreadyRegisteredError is
+// swallowed rather than panicking.
+func RegisterMetrics(reg prometheus.Registerer) {
+	for _, collector := range []prometheus.Collector{workqueueDepth, reconcileLatencySeconds, reconcileErrorsTotal, replicaState} {
+		if err := reg.Register(collector); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				klog.ErrorS(err, "failed to register CVR controller metric")
+			}
+		}
+	}
+}
+
+// ServeMetrics starts an HTTP server exposing /metrics on addr using the
+// default Prometheus registry, alongside the controller's Run loop.
+func ServeMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			klog.ErrorS(err, "metrics server exited", "addr", addr)
+		}
+	}()
+}
+
+// shardLabel renders a shard index as the Prometheus label value used for
+// the workqueue_depth gauge.
+func shardLabel(i int) string {
+	return strconv.Itoa(i)
+}
+
+// observeWorkqueueDepth reports the current depth of every shard. It is
+// cheap enough to call on a short ticker from Run.
+func (c *CStorVolumeReplicaController) observeWorkqueueDepth() {
+	for i, shard := range c.workqueues.shards {
+		workqueueDepth.WithLabelValues(shardLabel(i)).Set(float64(shard.Len()))
+	}
+}
+
+// replicaStates enumerates every value replica_state is reported for, so a
+// transition always zeroes out the state being left as well as setting the
+// one being entered.
+var replicaStates = []string{"Healthy", "Degraded", "Offline", "Rebuilding"}
+
+// observeReplicaState sets replica_state to 1 for the given CVR's current
+// state and 0 for every other known state.
+func observeReplicaState(name, current string) {
+	for _, state := range replicaStates {
+		value := 0.0
+		if state == current {
+			value = 1.0
+		}
+		replicaState.WithLabelValues(name, state).Set(value)
+	}
+}
+
+// clearReplicaState removes every replica_state series for name so a
+// destroyed CVR doesn't linger in the metric forever.
+func clearReplicaState(name string) {
+	for _, state := range replicaStates {
+		replicaState.DeleteLabelValues(name, state)
+	}
+}
+
+// observeReplicaStates samples replica_state for every CVR currently known
+// to the local cache. It is cheap enough to call on a ticker from Run,
+// keeping the gauge current for CVRs that haven't modified since the last
+// observation from modifyCVR.
+func (c *CStorVolumeReplicaController) observeReplicaStates() {
+	cVRs, err := c.cStorReplicaLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "failed to list CStorVolumeReplicas for replica_state sampling")
+		return
+	}
+	for _, cVR := range cVRs {
+		fullVolName := string(apis.PoolPrefix) + cVR.Name
+		if state, err := volumereplica.GetStatus(fullVolName); err == nil {
+			observeReplicaState(cVR.Name, state)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegisterMetricsIsIdempotent(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	RegisterMetrics(reg)
+	RegisterMetrics(reg)
+}
+
+func TestShardLabel(t *testing.T) {
+	if got := shardLabel(3); got != "3" {
+		t.Errorf("shardLabel(3) = %q, want %q", got, "3")
+	}
+}
+
+func TestObserveReplicaState(t *testing.T) {
+	defer replicaState.Reset()
+
+	observeReplicaState("pvc-1", "Healthy")
+
+	if got := testutil.ToFloat64(replicaState.WithLabelValues("pvc-1", "Healthy")); got != 1.0 {
+		t.Errorf("replica_state{state=Healthy} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(replicaState.WithLabelValues("pvc-1", "Degraded")); got != 0.0 {
+		t.Errorf("replica_state{state=Degraded} = %v, want 0", got)
+	}
+
+	observeReplicaState("pvc-1", "Degraded")
+	if got := testutil.ToFloat64(replicaState.WithLabelValues("pvc-1", "Healthy")); got != 0.0 {
+		t.Errorf("replica_state{state=Healthy} = %v after transitioning to Degraded, want 0", got)
+	}
+	if got := testutil.ToFloat64(replicaState.WithLabelValues("pvc-1", "Degraded")); got != 1.0 {
+		t.Errorf("replica_state{state=Degraded} = %v, want 1", got)
+	}
+}
+
+func TestClearReplicaState(t *testing.T) {
+	defer replicaState.Reset()
+
+	observeReplicaState("pvc-2", "Healthy")
+	clearReplicaState("pvc-2")
+
+	if collected := testutil.CollectAndCount(replicaState); collected != 0 {
+		t.Errorf("replica_state has %d series left after clearReplicaState(), want 0", collected)
+	}
+}
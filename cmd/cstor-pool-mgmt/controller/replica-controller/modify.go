@@ -0,0 +1,185 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/openebs/maya/cmd/cstor-pool-mgmt/controller/common"
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+	"github.com/openebs/maya/pkg/volumereplica"
+)
+
+// cvrSpecDiff captures the zfs dataset/zvol properties that differ between
+// the old and new CStorVolumeReplica spec and therefore need to be applied
+// on the pool via zfs/zpool commands.
+type cvrSpecDiff struct {
+	// CapacityChanged is true when spec.Capacity differs from the old spec,
+	// e.g. the bound PVC was expanded and the underlying zvol needs
+	// `zfs set volsize=`. A shrink is reported the same way as a growth.
+	CapacityChanged bool
+	NewCapacity     string
+
+	TargetIPChanged bool
+	NewTargetIP     string
+
+	QuorumChanged      bool
+	NewQuorum          string
+	CompressionChanged bool
+	NewCompression     string
+	DedupChanged       bool
+	NewDedup           string
+}
+
+// IsOnlySpecChange returns true when the update between oldCVR and newCVR
+// touches at least one of the properties this controller knows how to
+// reconcile online (capacity, target IP, quorum, compression, dedup).
+func IsOnlySpecChange(oldCVR, newCVR *apis.CStorVolumeReplica) bool {
+	diff := diffCVRSpec(oldCVR, newCVR)
+	return diff.CapacityChanged || diff.TargetIPChanged || diff.QuorumChanged ||
+		diff.CompressionChanged || diff.DedupChanged
+}
+
+// diffCVRSpec computes the cvrSpecDiff between the old and new spec.
+func diffCVRSpec(oldCVR, newCVR *apis.CStorVolumeReplica) cvrSpecDiff {
+	return diffSpec(oldCVR.Spec, newCVR.Spec)
+}
+
+// diffSpec computes the cvrSpecDiff between an old and new spec directly,
+// for callers such as modifyCVR that diff against a remembered spec rather
+// than another CStorVolumeReplica object.
+func diffSpec(oldSpec, newSpec apis.CStorVolumeReplicaSpec) cvrSpecDiff {
+	var diff cvrSpecDiff
+
+	if newSpec.Capacity != oldSpec.Capacity {
+		diff.CapacityChanged = true
+		diff.NewCapacity = newSpec.Capacity
+	}
+	if newSpec.TargetIP != oldSpec.TargetIP {
+		diff.TargetIPChanged = true
+		diff.NewTargetIP = newSpec.TargetIP
+	}
+	if newSpec.Quorum != oldSpec.Quorum {
+		diff.QuorumChanged = true
+		diff.NewQuorum = newSpec.Quorum
+	}
+	if newSpec.Compression != oldSpec.Compression {
+		diff.CompressionChanged = true
+		diff.NewCompression = newSpec.Compression
+	}
+	if newSpec.Dedup != oldSpec.Dedup {
+		diff.DedupChanged = true
+		diff.NewDedup = newSpec.Dedup
+	}
+
+	return diff
+}
+
+// appliedSpecs remembers, per CVR UID, the spec modifyCVR last successfully
+// applied to the zfs dataset. This lets a reconcile diff against what's
+// actually on the pool and only reissue the zfs/zpool commands for
+// properties that changed, instead of replaying every non-empty field on
+// every pass.
+type appliedSpecs struct {
+	mux   sync.Mutex
+	specs map[types.UID]apis.CStorVolumeReplicaSpec
+}
+
+func newAppliedSpecs() *appliedSpecs {
+	return &appliedSpecs{specs: make(map[types.UID]apis.CStorVolumeReplicaSpec)}
+}
+
+func (a *appliedSpecs) get(uid types.UID) apis.CStorVolumeReplicaSpec {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.specs[uid]
+}
+
+func (a *appliedSpecs) set(uid types.UID, spec apis.CStorVolumeReplicaSpec) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.specs[uid] = spec
+}
+
+func (a *appliedSpecs) delete(uid types.UID) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	delete(a.specs, uid)
+}
+
+// modifyCVR fetches the CStorVolumeReplica identified by key, diffs its spec
+// against the spec this controller last successfully applied to the zfs
+// dataset (c.appliedSpecs) and only issues the `zfs`/`zpool` calls for the
+// properties that actually changed, most importantly the online volume
+// expansion via `zfs set volsize=` when spec.Capacity has grown.
+func (c *CStorVolumeReplicaController) modifyCVR(key string) error {
+	cVR, err := c.getCVRByKey(key)
+	if err != nil {
+		return err
+	}
+
+	fullVolName := string(apis.PoolPrefix) + cVR.Name
+	diff := diffSpec(c.appliedSpecs.get(cVR.UID), cVR.Spec)
+
+	if diff.CapacityChanged {
+		if err := volumereplica.SetVolumeCapacity(fullVolName, diff.NewCapacity); err != nil {
+			c.recorder.Event(cVR, corev1.EventTypeWarning, common.FailureModify,
+				fmt.Sprintf("failed to resize volume to %s: %s", diff.NewCapacity, err.Error()))
+			return c.updateCVRCondition(cVR, apis.CStorVolumeReplicaResizePending, err.Error())
+		}
+	}
+
+	if diff.TargetIPChanged {
+		if err := volumereplica.SetTargetIP(fullVolName, diff.NewTargetIP); err != nil {
+			c.recorder.Event(cVR, corev1.EventTypeWarning, common.FailureModify,
+				fmt.Sprintf("failed to set target IP to %s: %s", diff.NewTargetIP, err.Error()))
+			return c.updateCVRCondition(cVR, apis.CStorVolumeReplicaResizePending, err.Error())
+		}
+	}
+
+	props := map[string]string{}
+	if diff.QuorumChanged {
+		props["quorum"] = diff.NewQuorum
+	}
+	if diff.CompressionChanged {
+		props["compression"] = diff.NewCompression
+	}
+	if diff.DedupChanged {
+		props["dedup"] = diff.NewDedup
+	}
+	if len(props) > 0 {
+		if err := volumereplica.SetVolumeProps(fullVolName, props); err != nil {
+			c.recorder.Event(cVR, corev1.EventTypeWarning, common.FailureModify, err.Error())
+			return c.updateCVRCondition(cVR, apis.CStorVolumeReplicaResizePending, err.Error())
+		}
+	}
+
+	c.appliedSpecs.set(cVR.UID, cVR.Spec)
+
+	if state, err := volumereplica.GetStatus(fullVolName); err == nil {
+		observeReplicaState(cVR.Name, state)
+	}
+
+	klog.InfoS("cStorVolumeReplica modify synced successfully", "name", cVR.Name, "uid", cVR.UID)
+	c.recorder.Event(cVR, corev1.EventTypeNormal, common.SuccessModify, common.MessageModifySynced)
+	return c.updateCVRCondition(cVR, apis.CStorVolumeReplicaResizeSynced, "")
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"testing"
+
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+)
+
+func cvrWithSpec(spec apis.CStorVolumeReplicaSpec) *apis.CStorVolumeReplica {
+	return &apis.CStorVolumeReplica{Spec: spec}
+}
+
+func TestDiffCVRSpec(t *testing.T) {
+	base := apis.CStorVolumeReplicaSpec{
+		Capacity:    "10Gi",
+		TargetIP:    "10.0.0.1",
+		Quorum:      "true",
+		Compression: "off",
+		Dedup:       "off",
+	}
+
+	tests := map[string]struct {
+		mutate func(apis.CStorVolumeReplicaSpec) apis.CStorVolumeReplicaSpec
+		want   cvrSpecDiff
+	}{
+		"no change": {
+			mutate: func(s apis.CStorVolumeReplicaSpec) apis.CStorVolumeReplicaSpec { return s },
+			want:   cvrSpecDiff{},
+		},
+		"capacity grown": {
+			mutate: func(s apis.CStorVolumeReplicaSpec) apis.CStorVolumeReplicaSpec {
+				s.Capacity = "20Gi"
+				return s
+			},
+			want: cvrSpecDiff{CapacityChanged: true, NewCapacity: "20Gi"},
+		},
+		"target IP changed": {
+			mutate: func(s apis.CStorVolumeReplicaSpec) apis.CStorVolumeReplicaSpec {
+				s.TargetIP = "10.0.0.2"
+				return s
+			},
+			want: cvrSpecDiff{TargetIPChanged: true, NewTargetIP: "10.0.0.2"},
+		},
+		"compression and dedup changed": {
+			mutate: func(s apis.CStorVolumeReplicaSpec) apis.CStorVolumeReplicaSpec {
+				s.Compression = "on"
+				s.Dedup = "on"
+				return s
+			},
+			want: cvrSpecDiff{CompressionChanged: true, NewCompression: "on", DedupChanged: true, NewDedup: "on"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			oldCVR := cvrWithSpec(base)
+			newCVR := cvrWithSpec(tt.mutate(base))
+
+			got := diffCVRSpec(oldCVR, newCVR)
+			if got != tt.want {
+				t.Errorf("diffCVRSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOnlySpecChange(t *testing.T) {
+	base := apis.CStorVolumeReplicaSpec{Capacity: "10Gi", TargetIP: "10.0.0.1"}
+
+	oldCVR := cvrWithSpec(base)
+	identical := cvrWithSpec(base)
+	if IsOnlySpecChange(oldCVR, identical) {
+		t.Error("IsOnlySpecChange() = true for an unchanged spec, want false")
+	}
+
+	resized := base
+	resized.Capacity = "20Gi"
+	if !IsOnlySpecChange(oldCVR, cvrWithSpec(resized)) {
+		t.Error("IsOnlySpecChange() = false for a capacity change, want true")
+	}
+}
+
+func TestAppliedSpecsRemembersPerUID(t *testing.T) {
+	a := newAppliedSpecs()
+
+	if got := a.get("uid-1"); got != (apis.CStorVolumeReplicaSpec{}) {
+		t.Errorf("get() = %+v for an unseen UID, want zero value", got)
+	}
+
+	spec := apis.CStorVolumeReplicaSpec{Capacity: "10Gi"}
+	a.set("uid-1", spec)
+	if got := a.get("uid-1"); got != spec {
+		t.Errorf("get() = %+v, want %+v", got, spec)
+	}
+	if got := a.get("uid-2"); got != (apis.CStorVolumeReplicaSpec{}) {
+		t.Errorf("get() = %+v for a different UID, want zero value", got)
+	}
+
+	a.delete("uid-1")
+	if got := a.get("uid-1"); got != (apis.CStorVolumeReplicaSpec{}) {
+		t.Errorf("get() = %+v after delete(), want zero value", got)
+	}
+}
+
+func TestDiffSpecOnlyReportsChangedFields(t *testing.T) {
+	oldSpec := apis.CStorVolumeReplicaSpec{Capacity: "10Gi", Compression: "off"}
+	newSpec := apis.CStorVolumeReplicaSpec{Capacity: "10Gi", Compression: "on"}
+
+	diff := diffSpec(oldSpec, newSpec)
+	if diff.CapacityChanged {
+		t.Error("diffSpec() reported CapacityChanged for an unchanged field")
+	}
+	if !diff.CompressionChanged || diff.NewCompression != "on" {
+		t.Errorf("diffSpec() = %+v, want CompressionChanged with NewCompression=on", diff)
+	}
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package replicacontroller
 
 import (
-	"github.com/golang/glog"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	kubeinformers "k8s.io/client-go/informers"
@@ -26,13 +25,14 @@ import (
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
 
 	"github.com/openebs/maya/cmd/cstor-pool-mgmt/controller/common"
 	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
 	clientset "github.com/openebs/maya/pkg/client/clientset/versioned"
 	openebsScheme "github.com/openebs/maya/pkg/client/clientset/versioned/scheme"
 	informers "github.com/openebs/maya/pkg/client/informers/externalversions"
+	listers "github.com/openebs/maya/pkg/client/listers/openebs.io/v1alpha1"
 )
 
 const replicaControllerName = "CStorVolumeReplica"
@@ -48,15 +48,30 @@ type CStorVolumeReplicaController struct {
 	// cStorReplicaSynced is used for caches sync to get populated
 	cStorReplicaSynced cache.InformerSynced
 
-	// workqueue is a rate limited work queue. This is used to queue work to be
-	// processed instead of performing it as soon as a change happens. This
-	// means we can ensure we only process a fixed amount of resources at a
-	// time, and makes it easy to ensure we are never processing the same item
-	// simultaneously in two different workers.
-	workqueue workqueue.RateLimitingInterface
+	// cStorReplicaLister indexes locally cached CVRs, used to sample
+	// replica_state for every known CVR on a ticker (see metrics.go).
+	cStorReplicaLister listers.CStorVolumeReplicaLister
+
+	// workqueues is a sharded set of rate limited work queues. This is used to
+	// queue work to be processed instead of performing it as soon as a change
+	// happens. CVRs are sharded by their own UID (see shard.go and
+	// enqueueCStorReplica) so different CVRs reconcile in parallel, while
+	// all events for the same CVR stay strictly ordered within their shard.
+	workqueues *shardedWorkqueue
 	// recorder is an event recorder for recording Event resources to the
 	// Kubernetes API.
 	recorder record.EventRecorder
+
+	// backupScheduler arms a per-CVR cron job for CVRs that carry a
+	// spec.BackupSchedule and takes/GCs zfs snapshots on each tick.
+	backupScheduler *backupScheduler
+
+	// appliedSpecs remembers the spec last successfully applied to zfs for
+	// each CVR, so modifyCVR only reissues commands for changed properties.
+	appliedSpecs *appliedSpecs
+
+	// config holds the tunables this controller was constructed with.
+	config Config
 }
 
 // NewCStorVolumeReplicaController returns a new cStor Replica controller instance
@@ -64,7 +79,8 @@ func NewCStorVolumeReplicaController(
 	kubeclientset kubernetes.Interface,
 	clientset clientset.Interface,
 	kubeInformerFactory kubeinformers.SharedInformerFactory,
-	cStorInformerFactory informers.SharedInformerFactory) *CStorVolumeReplicaController {
+	cStorInformerFactory informers.SharedInformerFactory,
+	config Config) *CStorVolumeReplicaController {
 
 	// obtain references to shared index informers for the cStorReplica resources.
 	cStorReplicaInformer := cStorInformerFactory.Openebs().V1alpha1().CStorVolumeReplicas()
@@ -74,9 +90,9 @@ func NewCStorVolumeReplicaController(
 	// Create event broadcaster
 	// Add cStor-Replica-controller types to the default Kubernetes Scheme so Events can be
 	// logged for cStor-Replica-controller types.
-	glog.V(4).Info("Creating event broadcaster")
+	klog.V(4).Info("Creating event broadcaster")
 	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(glog.Infof)
+	eventBroadcaster.StartLogging(klog.Infof)
 
 	// StartEventWatcher starts sending events received from this EventBroadcaster to the given
 	// event handler function. The return value can be ignored or used to stop recording, if
@@ -84,31 +100,57 @@ func NewCStorVolumeReplicaController(
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeclientset.CoreV1().Events("")})
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: replicaControllerName})
 
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.ResyncPeriod <= 0 {
+		config.ResyncPeriod = DefaultConfig().ResyncPeriod
+	}
+
 	controller := &CStorVolumeReplicaController{
 		kubeclientset:      kubeclientset,
 		clientset:          clientset,
 		cStorReplicaSynced: cStorReplicaInformer.Informer().HasSynced,
-		workqueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "CStorVolumeReplica"),
+		cStorReplicaLister: cStorReplicaInformer.Lister(),
+		workqueues:         newShardedWorkqueue(config.Workers, "CStorVolumeReplica"),
 		recorder:           recorder,
+		appliedSpecs:       newAppliedSpecs(),
+		config:             config,
 	}
+	controller.backupScheduler = newBackupScheduler(controller)
 
-	glog.Info("Setting up event handlers")
+	klog.Info("Setting up event handlers")
+
+	// chain is the predicate chain every event runs through before it is
+	// allowed to reach the handler below; see predicate.go.
+	chain := predicates()
 
 	// Instantiating QueueLoad before entering workqueue.
 	q := common.QueueLoad{}
 
 	// Set up an event handler for when cStorReplica resources change.
-	cStorReplicaInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	cStorReplicaInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			cVR := obj.(*apis.CStorVolumeReplica)
-			if !IsRightCStorVolumeReplica(cVR) {
+			if !runCreate(chain, cVR) {
 				return
 			}
-			if IsDeletionFailedBefore(cVR) {
+
+			// A restart can deliver an already-terminating CVR through
+			// AddFunc rather than UpdateFunc; route it to the same destroy
+			// path so it isn't stuck waiting for a spec update that never comes.
+			if cVR.ObjectMeta.DeletionTimestamp != nil {
+				controller.backupScheduler.Cancel(cVR.ObjectMeta.UID)
+				q.Operation = "destroy"
+				klog.InfoS("cStorVolumeReplica destroy event (observed on add)", "name", cVR.ObjectMeta.Name, "uid", cVR.ObjectMeta.UID)
+				controller.recorder.Event(cVR, corev1.EventTypeNormal, common.SuccessSynced, common.MessageDestroySynced)
+				controller.enqueueCStorReplica(obj, q)
 				return
 			}
+
+			controller.backupScheduler.Sync(cVR)
 			q.Operation = "add"
-			glog.Infof("cStorVolumeReplica Added event : %v, %v", cVR.ObjectMeta.Name, string(cVR.ObjectMeta.UID))
+			klog.InfoS("cStorVolumeReplica added", "name", cVR.ObjectMeta.Name, "uid", cVR.ObjectMeta.UID)
 			controller.recorder.Event(cVR, corev1.EventTypeNormal, common.SuccessSynced, common.MessageCreateSynced)
 			controller.enqueueCStorReplica(obj, q)
 		},
@@ -120,44 +162,54 @@ func NewCStorVolumeReplicaController(
 			if newCVR.ResourceVersion == oldCVR.ResourceVersion {
 				return
 			}
-			if !IsRightCStorVolumeReplica(newCVR) {
+			if !runUpdate(chain, oldCVR, newCVR) {
 				return
 			}
-			if IsOnlyStatusChange(oldCVR, newCVR) {
-				glog.Infof("Only cVR status change: %v, %v", newCVR.ObjectMeta.Name, string(newCVR.ObjectMeta.UID))
-				return
-			}
-			if IsDeletionFailedBefore(newCVR) {
-				return
-			}
-			if IsDestroyEvent(newCVR) {
+
+			if newCVR.ObjectMeta.DeletionTimestamp != nil {
+				// Cancel rather than (re-)arm the backup cron: a snapshot
+				// must not race the zfs destroy this update is about to queue.
+				controller.backupScheduler.Cancel(newCVR.ObjectMeta.UID)
 				q.Operation = "destroy"
-				glog.Infof("cStorVolumeReplica Destroy event : %v, %v", newCVR.ObjectMeta.Name, string(newCVR.ObjectMeta.UID))
+				klog.InfoS("cStorVolumeReplica destroy event", "name", newCVR.ObjectMeta.Name, "uid", newCVR.ObjectMeta.UID)
 				controller.recorder.Event(newCVR, corev1.EventTypeNormal, common.SuccessSynced, common.MessageDestroySynced)
 			} else {
+				controller.backupScheduler.Sync(newCVR)
+				if !IsOnlySpecChange(oldCVR, newCVR) {
+					return
+				}
 				q.Operation = "modify"
-				glog.Infof("cStorVolumeReplica Modify event : %v, %v", newCVR.ObjectMeta.Name, string(newCVR.ObjectMeta.UID))
+				klog.InfoS("cStorVolumeReplica modify event", "name", newCVR.ObjectMeta.Name, "uid", newCVR.ObjectMeta.UID)
 				controller.recorder.Event(newCVR, corev1.EventTypeNormal, common.SuccessSynced, common.MessageModifySynced)
-				return // will be removed once modify is implemented
 			}
 			controller.enqueueCStorReplica(new, q)
 		},
+		// The actual teardown happens in destroyCVR once the zfs dataset is
+		// destroyed and the protection finalizer is removed, so by the time
+		// the apiserver fires this event all that is left is to cancel the
+		// CVR's backup cron, if any.
 		DeleteFunc: func(obj interface{}) {
 			cVR := obj.(*apis.CStorVolumeReplica)
-			if !IsRightCStorVolumeReplica(cVR) {
+			if !runDelete(chain, cVR) {
 				return
 			}
-			q.Operation = "delete"
-			glog.Infof("\ncVR Resource deleted event: %v, %v", cVR.ObjectMeta.Name, string(cVR.ObjectMeta.UID))
+			klog.InfoS("cVR resource deleted event", "name", cVR.ObjectMeta.Name, "uid", cVR.ObjectMeta.UID)
+			controller.backupScheduler.Cancel(cVR.ObjectMeta.UID)
 		},
-	})
+	}, config.ResyncPeriod)
 
 	return controller
 }
 
-// enqueueCStorReplica takes a CStorReplica resource and converts it into a namespace/name
-// string which is then put onto the work queue. This method should *not* be
-// passed resources of any type other than CStorReplica.
+// enqueueCStorReplica takes a CStorReplica resource and converts it into a
+// namespace/name string which is then put onto the shard of the work queue
+// keyed by the CVR's own UID. Sharding by pool UID would be a no-op here:
+// isRightCVRPredicate already restricts this instance to a single pool's
+// CVRs, so every item would hash to the same shard. Sharding by CVR UID
+// instead gives real concurrency across the CVRs on that one pool, while
+// every event for a given CVR still lands on the same shard and therefore
+// stays ordered. This method should *not* be passed resources of any type
+// other than CStorReplica.
 func (c *CStorVolumeReplicaController) enqueueCStorReplica(obj interface{}, q common.QueueLoad) {
 	var key string
 	var err error
@@ -166,5 +218,10 @@ func (c *CStorVolumeReplicaController) enqueueCStorReplica(obj interface{}, q co
 		return
 	}
 	q.Key = key
-	c.workqueue.AddRateLimited(q)
+
+	shardKey := key
+	if cVR, ok := obj.(*apis.CStorVolumeReplica); ok {
+		shardKey = string(cVR.UID)
+	}
+	c.workqueues.shardFor(shardKey).AddRateLimited(q)
 }
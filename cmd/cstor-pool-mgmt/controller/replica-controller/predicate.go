@@ -0,0 +1,178 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"reflect"
+
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+)
+
+// cvrFinalizer protects a CStorVolumeReplica from being removed from etcd
+// before its backing zfs dataset has actually been destroyed. The sync
+// worker installs it on the "add" item AddFunc queues, and removes it once
+// `zfs destroy` succeeds.
+const cvrFinalizer = "cstorvolumereplica.openebs.io/protection"
+
+// Predicate is a kubebuilder-style event filter: each method decides whether
+// the corresponding informer event should be let through to the handler.
+// Composing small Predicates lets new filters be added without editing
+// AddFunc/UpdateFunc/DeleteFunc directly.
+type Predicate interface {
+	Create(cVR *apis.CStorVolumeReplica) bool
+	Update(oldCVR, newCVR *apis.CStorVolumeReplica) bool
+	Delete(cVR *apis.CStorVolumeReplica) bool
+	Generic(cVR *apis.CStorVolumeReplica) bool
+}
+
+// PredicateFuncs implements Predicate from individual functions. Any field
+// left nil defaults to "allow", mirroring controller-runtime's predicate.Funcs.
+type PredicateFuncs struct {
+	CreateFunc  func(cVR *apis.CStorVolumeReplica) bool
+	UpdateFunc  func(oldCVR, newCVR *apis.CStorVolumeReplica) bool
+	DeleteFunc  func(cVR *apis.CStorVolumeReplica) bool
+	GenericFunc func(cVR *apis.CStorVolumeReplica) bool
+}
+
+func (p PredicateFuncs) Create(cVR *apis.CStorVolumeReplica) bool {
+	if p.CreateFunc == nil {
+		return true
+	}
+	return p.CreateFunc(cVR)
+}
+
+func (p PredicateFuncs) Update(oldCVR, newCVR *apis.CStorVolumeReplica) bool {
+	if p.UpdateFunc == nil {
+		return true
+	}
+	return p.UpdateFunc(oldCVR, newCVR)
+}
+
+func (p PredicateFuncs) Delete(cVR *apis.CStorVolumeReplica) bool {
+	if p.DeleteFunc == nil {
+		return true
+	}
+	return p.DeleteFunc(cVR)
+}
+
+func (p PredicateFuncs) Generic(cVR *apis.CStorVolumeReplica) bool {
+	if p.GenericFunc == nil {
+		return true
+	}
+	return p.GenericFunc(cVR)
+}
+
+// predicates is the chain NewCStorVolumeReplicaController composes its event
+// handlers from. New filters can be appended here without touching the
+// handlers themselves.
+func predicates() []Predicate {
+	return []Predicate{
+		isRightCVRPredicate{},
+		notDeletionFailedPredicate{},
+		notOnlyStatusChangePredicate{},
+	}
+}
+
+// runCreate returns true only if every predicate in chain allows the create event.
+func runCreate(chain []Predicate, cVR *apis.CStorVolumeReplica) bool {
+	for _, p := range chain {
+		if !p.Create(cVR) {
+			return false
+		}
+	}
+	return true
+}
+
+// runUpdate returns true only if every predicate in chain allows the update event.
+func runUpdate(chain []Predicate, oldCVR, newCVR *apis.CStorVolumeReplica) bool {
+	for _, p := range chain {
+		if !p.Update(oldCVR, newCVR) {
+			return false
+		}
+	}
+	return true
+}
+
+// runDelete returns true only if every predicate in chain allows the delete event.
+func runDelete(chain []Predicate, cVR *apis.CStorVolumeReplica) bool {
+	for _, p := range chain {
+		if !p.Delete(cVR) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRightCVRPredicate keeps only CVRs scheduled onto this cstor-pool-mgmt
+// instance's pool, identified by the OwnerReference set on the CVR.
+type isRightCVRPredicate struct{}
+
+func (isRightCVRPredicate) Create(cVR *apis.CStorVolumeReplica) bool { return IsRightCStorVolumeReplica(cVR) }
+func (isRightCVRPredicate) Update(_, newCVR *apis.CStorVolumeReplica) bool {
+	return IsRightCStorVolumeReplica(newCVR)
+}
+func (isRightCVRPredicate) Delete(cVR *apis.CStorVolumeReplica) bool { return IsRightCStorVolumeReplica(cVR) }
+func (isRightCVRPredicate) Generic(cVR *apis.CStorVolumeReplica) bool { return IsRightCStorVolumeReplica(cVR) }
+
+// notDeletionFailedPredicate drops CVRs that a previous destroy attempt
+// already marked as failed, so a crash-looping zfs destroy doesn't spin the
+// workqueue; the operator has to intervene instead.
+type notDeletionFailedPredicate struct{}
+
+func (notDeletionFailedPredicate) Create(cVR *apis.CStorVolumeReplica) bool {
+	return !IsDeletionFailedBefore(cVR)
+}
+func (notDeletionFailedPredicate) Update(_, newCVR *apis.CStorVolumeReplica) bool {
+	return !IsDeletionFailedBefore(newCVR)
+}
+func (notDeletionFailedPredicate) Delete(cVR *apis.CStorVolumeReplica) bool { return true }
+func (notDeletionFailedPredicate) Generic(cVR *apis.CStorVolumeReplica) bool { return true }
+
+// notOnlyStatusChangePredicate drops update events that only touch
+// status (e.g. resync), since status-only writes never require re-reconciling.
+type notOnlyStatusChangePredicate struct{}
+
+func (notOnlyStatusChangePredicate) Create(cVR *apis.CStorVolumeReplica) bool { return true }
+func (notOnlyStatusChangePredicate) Update(oldCVR, newCVR *apis.CStorVolumeReplica) bool {
+	return !reflect.DeepEqual(oldCVR.Spec, newCVR.Spec) ||
+		!reflect.DeepEqual(oldCVR.ObjectMeta.DeletionTimestamp, newCVR.ObjectMeta.DeletionTimestamp) ||
+		!reflect.DeepEqual(oldCVR.ObjectMeta.Finalizers, newCVR.ObjectMeta.Finalizers)
+}
+func (notOnlyStatusChangePredicate) Delete(cVR *apis.CStorVolumeReplica) bool { return true }
+func (notOnlyStatusChangePredicate) Generic(cVR *apis.CStorVolumeReplica) bool { return true }
+
+// hasCVRFinalizer reports whether cVR still carries the protection finalizer.
+func hasCVRFinalizer(cVR *apis.CStorVolumeReplica) bool {
+	for _, f := range cVR.ObjectMeta.Finalizers {
+		if f == cvrFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCVRFinalizer returns a copy of in with the protection finalizer
+// removed, leaving every other finalizer untouched.
+func removeCVRFinalizer(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, f := range in {
+		if f != cvrFinalizer {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+)
+
+func TestPredicateFuncsDefaultsAllow(t *testing.T) {
+	p := PredicateFuncs{}
+	cVR := &apis.CStorVolumeReplica{}
+
+	if !p.Create(cVR) {
+		t.Error("Create() = false for a zero-value PredicateFuncs, want true")
+	}
+	if !p.Update(cVR, cVR) {
+		t.Error("Update() = false for a zero-value PredicateFuncs, want true")
+	}
+	if !p.Delete(cVR) {
+		t.Error("Delete() = false for a zero-value PredicateFuncs, want true")
+	}
+	if !p.Generic(cVR) {
+		t.Error("Generic() = false for a zero-value PredicateFuncs, want true")
+	}
+}
+
+func TestRunCreateStopsAtFirstFalse(t *testing.T) {
+	calledSecond := false
+	chain := []Predicate{
+		PredicateFuncs{CreateFunc: func(*apis.CStorVolumeReplica) bool { return false }},
+		PredicateFuncs{CreateFunc: func(*apis.CStorVolumeReplica) bool { calledSecond = true; return true }},
+	}
+
+	if runCreate(chain, &apis.CStorVolumeReplica{}) {
+		t.Error("runCreate() = true, want false when the first predicate in chain rejects")
+	}
+	if calledSecond {
+		t.Error("runCreate() evaluated a predicate after an earlier one already rejected the event")
+	}
+}
+
+func TestRunUpdateRejectsWhenAnyPredicateRejects(t *testing.T) {
+	chain := []Predicate{
+		PredicateFuncs{UpdateFunc: func(_, _ *apis.CStorVolumeReplica) bool { return true }},
+		PredicateFuncs{UpdateFunc: func(_, _ *apis.CStorVolumeReplica) bool { return false }},
+	}
+
+	if runUpdate(chain, &apis.CStorVolumeReplica{}, &apis.CStorVolumeReplica{}) {
+		t.Error("runUpdate() = true, want false when a predicate in chain rejects")
+	}
+}
+
+func TestRunDeleteRejectsWhenPredicateRejects(t *testing.T) {
+	chain := []Predicate{
+		PredicateFuncs{DeleteFunc: func(*apis.CStorVolumeReplica) bool { return false }},
+	}
+
+	if runDelete(chain, &apis.CStorVolumeReplica{}) {
+		t.Error("runDelete() = true, want false when a predicate in chain rejects")
+	}
+}
+
+func TestNotOnlyStatusChangePredicateUpdate(t *testing.T) {
+	p := notOnlyStatusChangePredicate{}
+	now := metav1.Now()
+
+	base := &apis.CStorVolumeReplica{
+		Spec: apis.CStorVolumeReplicaSpec{Capacity: "10Gi"},
+	}
+
+	statusOnly := base.DeepCopy()
+	statusOnly.Status.Phase = "Healthy"
+	if p.Update(base, statusOnly) {
+		t.Error("Update() = true for a status-only change, want false")
+	}
+
+	specChanged := base.DeepCopy()
+	specChanged.Spec.Capacity = "20Gi"
+	if !p.Update(base, specChanged) {
+		t.Error("Update() = false for a spec change, want true")
+	}
+
+	deleting := base.DeepCopy()
+	deleting.ObjectMeta.DeletionTimestamp = &now
+	if !p.Update(base, deleting) {
+		t.Error("Update() = false for a DeletionTimestamp change, want true")
+	}
+
+	finalized := base.DeepCopy()
+	finalized.ObjectMeta.Finalizers = []string{cvrFinalizer}
+	if !p.Update(base, finalized) {
+		t.Error("Update() = false for a Finalizers change, want true")
+	}
+}
+
+func TestHasCVRFinalizer(t *testing.T) {
+	cVR := &apis.CStorVolumeReplica{}
+	if hasCVRFinalizer(cVR) {
+		t.Error("hasCVRFinalizer() = true for a CVR with no finalizers, want false")
+	}
+
+	cVR.ObjectMeta.Finalizers = []string{"other.finalizer", cvrFinalizer}
+	if !hasCVRFinalizer(cVR) {
+		t.Error("hasCVRFinalizer() = false for a CVR carrying cvrFinalizer, want true")
+	}
+}
+
+func TestRemoveCVRFinalizer(t *testing.T) {
+	got := removeCVRFinalizer([]string{"other.finalizer", cvrFinalizer})
+	want := []string{"other.finalizer"}
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("removeCVRFinalizer() = %v, want %v", got, want)
+	}
+
+	if got := removeCVRFinalizer([]string{"other.finalizer"}); len(got) != 1 || got[0] != "other.finalizer" {
+		t.Errorf("removeCVRFinalizer() = %v, want untouched slice with no cvrFinalizer present", got)
+	}
+}
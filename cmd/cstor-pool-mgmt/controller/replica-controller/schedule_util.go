@@ -0,0 +1,42 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"time"
+
+	"github.com/robfig/cron"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// metav1Now wraps time.Now in a metav1.Time, kept as a single indirection
+// point so status timestamps stay consistent if we ever need to inject a
+// clock for testing.
+func metav1Now() metav1.Time {
+	return metav1.NewTime(time.Now())
+}
+
+// nextScheduleTime returns the next activation time for a cron expression
+// after `from`, or nil if the expression cannot be parsed.
+func nextScheduleTime(schedule string, from time.Time) *metav1.Time {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil
+	}
+	next := metav1.NewTime(sched.Next(from))
+	return &next
+}
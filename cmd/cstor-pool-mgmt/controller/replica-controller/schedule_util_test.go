@@ -0,0 +1,41 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextScheduleTime(t *testing.T) {
+	from := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	next := nextScheduleTime("0 * * * *", from)
+	if next == nil {
+		t.Fatal("nextScheduleTime() = nil, want a valid time")
+	}
+	want := time.Date(2020, time.January, 1, 1, 0, 0, 0, time.UTC)
+	if !next.Time.Equal(want) {
+		t.Errorf("nextScheduleTime() = %v, want %v", next.Time, want)
+	}
+}
+
+func TestNextScheduleTimeInvalidExpression(t *testing.T) {
+	if next := nextScheduleTime("not-a-cron-expression", time.Now()); next != nil {
+		t.Errorf("nextScheduleTime() = %v, want nil for an invalid expression", next)
+	}
+}
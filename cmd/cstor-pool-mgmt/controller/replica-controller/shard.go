@@ -0,0 +1,63 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// shardedWorkqueue fans CVR reconciles out across shards keyed by CVR UID,
+// so that a single cstor-pool-mgmt instance — which per isRightCVRPredicate
+// only ever owns CVRs belonging to its one pool — still gets real
+// concurrency across those CVRs, while all events for the same CVR stay on
+// one shard and therefore strictly ordered relative to each other.
+type shardedWorkqueue struct {
+	shards []workqueue.RateLimitingInterface
+}
+
+// newShardedWorkqueue creates a shardedWorkqueue with the given number of
+// shards, each a standard named rate-limiting queue.
+func newShardedWorkqueue(shardCount int, name string) *shardedWorkqueue {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	shards := make([]workqueue.RateLimitingInterface, shardCount)
+	for i := range shards {
+		shards[i] = workqueue.NewNamedRateLimitingQueue(
+			workqueue.DefaultControllerRateLimiter(),
+			fmt.Sprintf("%s-shard-%d", name, i),
+		)
+	}
+	return &shardedWorkqueue{shards: shards}
+}
+
+// shardFor returns the shard a given key is assigned to.
+func (s *shardedWorkqueue) shardFor(key string) workqueue.RateLimitingInterface {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// ShutDown shuts down every shard.
+func (s *shardedWorkqueue) ShutDown() {
+	for _, shard := range s.shards {
+		shard.ShutDown()
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import "testing"
+
+func TestShardForIsStable(t *testing.T) {
+	swq := newShardedWorkqueue(4, "test")
+	defer swq.ShutDown()
+
+	first := swq.shardFor("pool-a")
+	second := swq.shardFor("pool-a")
+	if first != second {
+		t.Error("shardFor() returned different shards for the same pool UID across calls")
+	}
+}
+
+func TestShardForDistributesAcrossShards(t *testing.T) {
+	swq := newShardedWorkqueue(4, "test")
+	defer swq.ShutDown()
+
+	seen := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		shard := swq.shardFor(string(rune('a' + i)))
+		for idx, s := range swq.shards {
+			if s == shard {
+				seen[idx] = true
+			}
+		}
+	}
+
+	if len(seen) < 2 {
+		t.Errorf("shardFor() only ever picked %d distinct shard(s) out of %d across 100 keys", len(seen), len(swq.shards))
+	}
+}
+
+func TestNewShardedWorkqueueMinimumOneShard(t *testing.T) {
+	swq := newShardedWorkqueue(0, "test")
+	defer swq.ShutDown()
+
+	if len(swq.shards) != 1 {
+		t.Errorf("newShardedWorkqueue(0, ...) created %d shards, want 1", len(swq.shards))
+	}
+}
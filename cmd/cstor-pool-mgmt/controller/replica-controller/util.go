@@ -0,0 +1,61 @@
+/*
+Copyright 2018 The OpenEBS Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replicacontroller
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apis "github.com/openebs/maya/pkg/apis/openebs.io/v1alpha1"
+)
+
+// getCVRByKey fetches the latest CStorVolumeReplica for a namespace/name key
+// directly from the apiserver so reconcilers always act on the freshest spec.
+func (c *CStorVolumeReplicaController) getCVRByKey(key string) (*apis.CStorVolumeReplica, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.clientset.OpenebsV1alpha1().CStorVolumeReplicas(namespace).Get(name, metav1.GetOptions{})
+}
+
+// updateCVRCondition sets the given condition type on cVR.Status.Conditions
+// (replacing any existing condition of the same type) and persists the
+// status update. An empty message clears the condition's error message.
+func (c *CStorVolumeReplicaController) updateCVRCondition(cVR *apis.CStorVolumeReplica, condType apis.CStorVolumeReplicaConditionType, message string) error {
+	cVRCopy := cVR.DeepCopy()
+
+	condition := apis.CStorVolumeReplicaCondition{
+		Type:    condType,
+		Message: message,
+	}
+
+	updated := false
+	for i, existing := range cVRCopy.Status.Conditions {
+		if existing.Type == condType {
+			cVRCopy.Status.Conditions[i] = condition
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		cVRCopy.Status.Conditions = append(cVRCopy.Status.Conditions, condition)
+	}
+
+	_, err := c.clientset.OpenebsV1alpha1().CStorVolumeReplicas(cVRCopy.Namespace).Update(cVRCopy)
+	return err
+}